@@ -0,0 +1,78 @@
+/*
+ * per-host circuit breaker, driven by the same Feedback calls that
+ * drive HRW penalties
+ */
+
+package memcache
+
+import (
+	"sync"
+	"time"
+)
+
+// breakerThreshold is how many consecutive Feedback failures for a host
+// trip its circuit open.
+const breakerThreshold = 5
+
+// breakerCooldown is how long a tripped circuit stays open, unless a
+// Feedback success closes it sooner.
+const breakerCooldown = 5 * time.Second
+
+// hostBreaker is one host's circuit-breaker state.
+type hostBreaker struct {
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+// HealthAware is implemented by schedulers that track per-host health
+// from Feedback. read-repair (and anything else writing outside the
+// normal request path) consults it so a host Feedback has already
+// flagged as struggling isn't also hammered by repair traffic.
+type HealthAware interface {
+	IsHealthy(host *Host) bool
+}
+
+// breakers is embedded by schedulers that want HealthAware for free.
+type breakers struct {
+	lock  sync.Mutex
+	hosts map[*Host]*hostBreaker
+}
+
+func (b *breakers) recordFailure(host *Host) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.hosts == nil {
+		b.hosts = make(map[*Host]*hostBreaker)
+	}
+	hb, ok := b.hosts[host]
+	if !ok {
+		hb = &hostBreaker{}
+		b.hosts[host] = hb
+	}
+	hb.consecutiveFailures++
+	if hb.consecutiveFailures >= breakerThreshold {
+		hb.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+func (b *breakers) recordSuccess(host *Host) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if hb, ok := b.hosts[host]; ok {
+		hb.consecutiveFailures = 0
+		hb.openUntil = time.Time{}
+	}
+}
+
+// IsHealthy reports false while host's circuit is open, i.e. while it's
+// within breakerCooldown of tripping breakerThreshold consecutive
+// Feedback failures.
+func (b *breakers) IsHealthy(host *Host) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	hb, ok := b.hosts[host]
+	if !ok {
+		return true
+	}
+	return time.Now().After(hb.openUntil)
+}
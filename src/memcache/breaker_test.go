@@ -0,0 +1,45 @@
+package memcache
+
+import "testing"
+
+func TestBreakersTripsAfterThresholdFailures(t *testing.T) {
+	var b breakers
+	host := NewHost("127.0.0.1:0", 0)
+
+	for i := 0; i < breakerThreshold-1; i++ {
+		b.recordFailure(host)
+	}
+	if !b.IsHealthy(host) {
+		t.Fatalf("host should still be healthy before hitting breakerThreshold")
+	}
+
+	b.recordFailure(host)
+	if b.IsHealthy(host) {
+		t.Fatalf("host should be unhealthy once breakerThreshold consecutive failures are recorded")
+	}
+
+	b.recordSuccess(host)
+	if !b.IsHealthy(host) {
+		t.Fatalf("a success should close the circuit immediately")
+	}
+}
+
+func TestRepairerSkipsUnhealthyHost(t *testing.T) {
+	s := NewRendezvousScheduler()
+	host := NewHost("127.0.0.1:0", 0)
+	s.AddHost(host)
+
+	for i := 0; i < breakerThreshold; i++ {
+		s.Feedback(host, "some-key", -10, true)
+	}
+	if s.IsHealthy(host) {
+		t.Fatalf("scheduler should report the host unhealthy after repeated Feedback failures")
+	}
+
+	r := newRepairer(s)
+	r.processJob(repairJob{hosts: []*Host{host}, key: "k", item: &Item{Body: []byte("v")}})
+	stats := r.stats.Snapshot()
+	if stats.Attempted != 0 {
+		t.Fatalf("repair should not have attempted a write to an unhealthy host, got %d attempts", stats.Attempted)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * bucket map: a versioned slot table for live rebalancing, analogous to
+ * a Redis Cluster slot table.
+ */
+
+package memcache
+
+import "sync"
+
+// BucketState is where a bucket is in its migration lifecycle.
+type BucketState int
+
+const (
+	// BucketStable means Owner is authoritative; no migration in
+	// progress.
+	BucketStable BucketState = iota
+	// BucketMigrating means keys are being copied from Owner to
+	// NewOwner; both must be consulted until the bucket goes Stable.
+	BucketMigrating
+)
+
+// Bucket maps one slot to its current owner (and replicas), plus
+// whatever migration is in flight for it.
+type Bucket struct {
+	Owner    string   // host addr currently authoritative for writes
+	Replicas []string // secondary addrs, not including Owner
+	State    BucketState
+	NewOwner string // target owner while State == BucketMigrating
+}
+
+// BucketMap is a versioned slot table: Eye.Buckets fixed-size buckets,
+// each owned by a primary plus N-1 replicas. SetBucketMap swaps the
+// whole table atomically, so readers never see a partially-updated map.
+type BucketMap struct {
+	Version uint64
+	Buckets []Bucket
+}
+
+// NewBucketMap builds an n-bucket map with every bucket Stable and
+// unowned; callers fill in Owner/Replicas before installing it with
+// SetBucketMap.
+func NewBucketMap(version uint64, n int) *BucketMap {
+	return &BucketMap{Version: version, Buckets: make([]Bucket, n)}
+}
+
+// BucketFor returns which bucket key falls into, or -1 if m has no
+// buckets at all. callers must check for -1 before indexing m.Buckets
+// with the result.
+func (m *BucketMap) BucketFor(key string) int {
+	if len(m.Buckets) == 0 {
+		return -1
+	}
+	return int(hash64([]byte(key), 0) % uint64(len(m.Buckets)))
+}
+
+// BucketAware is implemented by schedulers that support live
+// rebalancing via a BucketMap. Client type-asserts the configured
+// Scheduler against this interface, so schedulers that don't need
+// rebalancing (or predate it) aren't forced to implement it.
+type BucketAware interface {
+	// SetBucketMap atomically installs m as the current bucket map,
+	// provided v is newer than whatever version is installed; a stale v
+	// is a no-op, so a slow admin request can't roll the map backwards.
+	SetBucketMap(v uint64, m *BucketMap)
+	// CurrentBucketMap returns the installed map, or nil if none has
+	// been set yet.
+	CurrentBucketMap() *BucketMap
+}
+
+// bucketMapHolder is embedded by schedulers that want BucketAware for
+// free.
+type bucketMapHolder struct {
+	lock sync.RWMutex
+	m    *BucketMap
+}
+
+func (h *bucketMapHolder) SetBucketMap(v uint64, m *BucketMap) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.m != nil && v <= h.m.Version {
+		return
+	}
+	h.m = m
+}
+
+func (h *bucketMapHolder) CurrentBucketMap() *BucketMap {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.m
+}
+
+// hostsForBucket resolves a Bucket to the live *Host objects the
+// scheduler currently knows about, by matching addrs. a bucket
+// referencing a host that has since been removed is simply skipped.
+func hostsForBucket(candidates []*Host, b Bucket) (owner, newOwner *Host, replicas []*Host) {
+	byAddr := make(map[string]*Host, len(candidates))
+	for _, h := range candidates {
+		byAddr[h.Addr] = h
+	}
+	owner = byAddr[b.Owner]
+	if b.State == BucketMigrating {
+		newOwner = byAddr[b.NewOwner]
+	}
+	for _, addr := range b.Replicas {
+		if h, ok := byAddr[addr]; ok {
+			replicas = append(replicas, h)
+		}
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package memcache
+
+import "testing"
+
+func TestBucketMapBucketForEmpty(t *testing.T) {
+	m := &BucketMap{Version: 1}
+	if i := m.BucketFor("anything"); i != -1 {
+		t.Fatalf("BucketFor on an empty map = %d, want -1", i)
+	}
+}
+
+func TestBucketMapBucketForStable(t *testing.T) {
+	m := NewBucketMap(1, 16)
+	i := m.BucketFor("some-key")
+	if i < 0 || i >= len(m.Buckets) {
+		t.Fatalf("BucketFor returned out-of-range index %d for %d buckets", i, len(m.Buckets))
+	}
+	// same key must always land in the same bucket
+	if j := m.BucketFor("some-key"); j != i {
+		t.Fatalf("BucketFor not stable across calls: %d then %d", i, j)
+	}
+}
+
+func TestGetHostsByKeyHonorsOwnerDuringMigration(t *testing.T) {
+	s := NewRendezvousScheduler()
+	a := NewHost("10.0.0.1:11211", 0)
+	b := NewHost("10.0.0.2:11211", 0)
+	s.AddHost(a)
+	s.AddHost(b)
+
+	m := NewBucketMap(1, 1)
+	m.Buckets[0] = Bucket{Owner: a.Addr, State: BucketMigrating, NewOwner: b.Addr}
+	s.SetBucketMap(1, m)
+
+	hosts := s.GetHostsByKey("any-key")
+	if len(hosts) != 2 {
+		t.Fatalf("expected both hosts back, got %d", len(hosts))
+	}
+	if hosts[0] != b {
+		t.Fatalf("expected the migrating bucket's NewOwner ranked first, got %s", hosts[0].Addr)
+	}
+	if hosts[1] != a {
+		t.Fatalf("expected the migrating bucket's old Owner ranked second, got %s", hosts[1].Addr)
+	}
+}
+
+func TestInstalledEmptyBucketMapRoutesNothing(t *testing.T) {
+	// the proxy's /rebalance handler rejects an empty BucketMap itself;
+	// SetBucketMap does not, so here we check that an empty map, once
+	// installed, still maps no key to a bucket.
+	s := NewRendezvousScheduler()
+	empty := &BucketMap{Version: 1, Buckets: nil}
+	s.SetBucketMap(1, empty)
+	if i := empty.BucketFor("x"); i != -1 {
+		t.Fatalf("an installed empty map must still report BucketFor = -1, got %d", i)
+	}
+}
@@ -23,6 +23,13 @@ type Client struct {
 	scheduler Scheduler
 	N, W, R   int
 	success   chan bool
+	repair    *repairer
+
+	hedged      bool
+	p99Estimate time.Duration
+
+	inflight     chan struct{}
+	inflightOnce sync.Once
 }
 
 func NewClient(sch Scheduler, N, W, R int) (c *Client) {
@@ -35,13 +42,20 @@ func NewClient(sch Scheduler, N, W, R int) (c *Client) {
 }
 
 func (c *Client) Get(key string) (r *Item, targets []string, err error) {
+	// GetHostsByKey already puts the BucketMap's declared owner(s) for
+	// key's bucket ahead of plain HRW ranking, so a migration is
+	// reflected here with no extra bookkeeping.
 	hosts := c.scheduler.GetHostsByKey(key)
 	cnt := 0
+	var missed, errored []*Host
 	for i, host := range hosts {
 		st := time.Now()
 		r, err = host.Get(key)
 		if err != nil {
 			c.scheduler.Feedback(host, key, -10, true)
+			if i < c.N {
+				errored = append(errored, host)
+			}
 		} else {
 			cnt++
 			if r != nil {
@@ -53,9 +67,11 @@ func (c *Client) Get(key string) (r *Item, targets []string, err error) {
 				// got the right rval
 				targets = []string{host.Addr}
 				err = nil
+				c.repairStale(key, r, missed, errored)
 				//return r, nil
 				return
 			}
+			missed = append(missed, host)
 		}
 		if cnt >= c.R && i+1 >= c.N {
 			// because hosts are sorted
@@ -68,41 +84,110 @@ func (c *Client) Get(key string) (r *Item, targets []string, err error) {
 		}
 	}
 	// here is a failure exit
+	if err == nil {
+		err = c.migrationStatus(key, hosts)
+	}
 	return
 }
 
+// migrationStatus turns a plain miss into a more informative error when
+// key's bucket is involved in a rebalance, so a caller (or a
+// coordinating proxy) doesn't treat "being moved right now" the same as
+// "truly not cached anywhere":
+//
+//   - ErrMigrating: the bucket is still being drained; the value may
+//     simply not have been copied to the new owner yet.
+//   - ErrMoved: hosts was ranked against a BucketMap that has since been
+//     swapped out from under us (e.g. a Migrator just flipped this
+//     bucket to Stable under a new owner between GetHostsByKey and now)
+//     — retry against a freshly-fetched host list rather than trusting
+//     this miss.
+func (c *Client) migrationStatus(key string, hosts []*Host) error {
+	ba, ok := c.scheduler.(BucketAware)
+	if !ok {
+		return nil
+	}
+	m := ba.CurrentBucketMap()
+	if m == nil {
+		return nil
+	}
+	i := m.BucketFor(key)
+	if i < 0 {
+		return nil
+	}
+	b := m.Buckets[i]
+	if b.State == BucketMigrating {
+		return ErrMigrating
+	}
+	if b.State == BucketStable && len(hosts) > 0 && b.Owner != "" && hosts[0].Addr != b.Owner {
+		return ErrMoved
+	}
+	return nil
+}
+
+// repairStale asynchronously writes value back to the replicas that
+// returned nil before the one that actually had it, and to those among
+// the first c.N that errored before it, so they
+// don't keep serving misses for key. no-op unless EnableReadRepair(true)
+// was called.
+func (c *Client) repairStale(key string, value *Item, missed, errored []*Host) {
+	if c.repair == nil || !c.repair.enabled || value == nil {
+		return
+	}
+	stale := append(append([]*Host{}, missed...), errored...)
+	c.repair.submit(stale, key, value)
+}
+
+// fanoutReplicas is how many of the sorted replicas getMulti queries
+// concurrently per round; later rounds query the next fanoutReplicas
+// replicas, again concurrently, for whatever keys are still missing.
+const fanoutReplicas = 2
+
+// getMulti fetches keys from a bucket's replicas, pipelined: the first
+// fanoutReplicas hosts (bounded by c.N) are queried concurrently and
+// merged as they answer, so one slow-but-not-dead replica no longer
+// stalls the rest. Any keys still missing afterward are re-issued, again
+// in parallel, only to the replicas that weren't queried yet.
 func (c *Client) getMulti(keys []string) (rs map[string]*Item, targets []string, err error) {
 	need := len(keys)
 	rs = make(map[string]*Item, need)
 	hosts := c.scheduler.GetHostsByKey(keys[0])
+
 	suc := 0
-	for i, host := range hosts {
-		st := time.Now()
-		r, er := host.GetMulti(keys)
-		if er != nil { // failed
-			err = er
-			c.scheduler.Feedback(host, keys[0], -10, true)
-		} else {
-			suc += 1
-			targets = append(targets, host.Addr)
+	next := 0
+	for next < len(hosts) && len(rs) < need {
+		batch := fanoutReplicas
+		if c.N > 0 && batch > c.N {
+			batch = c.N
+		}
+		if remaining := len(hosts) - next; batch > remaining {
+			batch = remaining
 		}
+		round := hosts[next : next+batch]
+		next += batch
 
-		t := float64(time.Now().Sub(st)) / 1e9
-		c.scheduler.Feedback(host, keys[0], -float64(math.Sqrt(t)*t), false)
-		for k, v := range r {
-			rs[k] = v
+		for _, v := range c.fanoutGetMulti(round, keys) {
+			if v.err == nil {
+				suc++
+				targets = append(targets, v.host.Addr)
+			} else {
+				err = v.err
+			}
+			for kk, vv := range v.items {
+				rs[kk] = vv
+			}
 		}
 
 		if len(rs) == need {
 			break
 		}
-		if i+1 >= c.N && suc >= c.R {
+		if next >= c.N && suc >= c.R {
 			err = nil
 			targets = []string{}
 			break
 		}
 
-		new_keys := []string{}
+		new_keys := make([]string, 0, len(keys))
 		for _, k := range keys {
 			if _, ok := rs[k]; !ok {
 				new_keys = append(new_keys, k)
@@ -119,6 +204,56 @@ func (c *Client) getMulti(keys []string) (rs map[string]*Item, targets []string,
 	return
 }
 
+// hostMultiResult is one replica's answer to a fanned-out GetMulti call.
+type hostMultiResult struct {
+	host  *Host
+	items map[string]*Item
+	err   error
+}
+
+// fanoutGetMulti queries hosts concurrently for keys, bounded by the
+// client's shared inflight semaphore so a single large multi-get can't
+// exhaust the host connection pool on its own.
+func (c *Client) fanoutGetMulti(hosts []*Host, keys []string) []hostMultiResult {
+	results := make([]hostMultiResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host *Host) {
+			defer wg.Done()
+			c.acquireInflight()
+			defer c.releaseInflight()
+
+			st := time.Now()
+			r, er := host.GetMulti(keys)
+			t := float64(time.Now().Sub(st)) / 1e9
+			if er != nil {
+				c.scheduler.Feedback(host, keys[0], -10, true)
+			} else {
+				c.scheduler.Feedback(host, keys[0], -float64(math.Sqrt(t)*t), false)
+			}
+			results[i] = hostMultiResult{host: host, items: r, err: er}
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+// defaultInflight bounds how many per-host GetMulti calls may be in
+// flight at once across every bucket goroutine a single Client's
+// GetMulti spawns, regardless of how many keys the caller asked for in
+// one GetMulti. GetMultiContext's per-host calls do not go through it.
+const defaultInflight = 64
+
+func (c *Client) acquireInflight() {
+	c.inflightOnce.Do(func() { c.inflight = make(chan struct{}, defaultInflight) })
+	c.inflight <- struct{}{}
+}
+
+func (c *Client) releaseInflight() {
+	<-c.inflight
+}
+
 func (c *Client) GetMulti(keys []string) (rs map[string]*Item, targets []string, err error) {
 	var lock sync.Mutex
 	rs = make(map[string]*Item, len(keys))
@@ -250,7 +385,3 @@ func (c *Client) Delete(key string) (r bool, targets []string, err error) {
 	//return suc >= c.W, err
 	return
 }
-
-func (c *Client) Len() int {
-	return 0
-}
@@ -0,0 +1,77 @@
+package memcache
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closedPortHost returns a Host pointed at a port nothing is listening on,
+// so dialing it fails fast with "connection refused" instead of hanging.
+func closedPortHost(t *testing.T) *Host {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return NewHost(addr, 50*time.Millisecond)
+}
+
+func TestFanoutGetMultiCollectsPerHostResults(t *testing.T) {
+	s := NewRendezvousScheduler()
+	c := NewClient(s, 1, 1, 1)
+	hosts := []*Host{closedPortHost(t), closedPortHost(t)}
+	for _, h := range hosts {
+		s.AddHost(h)
+	}
+
+	results := c.fanoutGetMulti(hosts, []string{"k"})
+	if len(results) != len(hosts) {
+		t.Fatalf("fanoutGetMulti returned %d results, want %d", len(results), len(hosts))
+	}
+	for i, r := range results {
+		if r.host != hosts[i] {
+			t.Errorf("result %d is for host %v, want %v", i, r.host, hosts[i])
+		}
+		if r.err == nil {
+			t.Errorf("result %d: expected a dial error against an unused port, got nil", i)
+		}
+	}
+}
+
+func TestInflightSemaphoreBoundsConcurrency(t *testing.T) {
+	c := NewClient(NewRendezvousScheduler(), 1, 1, 1)
+
+	var lock sync.Mutex
+	var cur, peak int
+	var wg sync.WaitGroup
+	for i := 0; i < defaultInflight*3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.acquireInflight()
+			defer c.releaseInflight()
+
+			lock.Lock()
+			cur++
+			if cur > peak {
+				peak = cur
+			}
+			lock.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			lock.Lock()
+			cur--
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if peak > defaultInflight {
+		t.Errorf("observed %d concurrent inflight callers, want at most defaultInflight (%d)", peak, defaultInflight)
+	}
+}
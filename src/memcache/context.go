@@ -0,0 +1,306 @@
+/*
+ * context-aware variants of Client's operations
+ */
+
+package memcache
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// GetContext is Get with a deadline: the host loop stops as soon as ctx
+// is done, and whatever budget is left on ctx is pushed down into each
+// host.Get so the underlying socket doesn't outlive the caller's
+// patience.
+func (c *Client) GetContext(ctx context.Context, key string) (r *Item, targets []string, err error) {
+	hosts := c.scheduler.GetHostsByKey(key)
+
+	hr, ht, herr, handled, queried, hedgeMissed := c.hedgedGet(ctx, hosts, key)
+	if handled {
+		return hr, ht, herr
+	}
+
+	cnt := 0
+	missed := append([]*Host{}, hedgeMissed...)
+	var errored []*Host
+	// hedgedGet already queried hosts[:len(queried)]; don't re-issue those
+	// requests or double-count their Feedback.
+	for i := len(queried); i < len(hosts); i++ {
+		host := hosts[i]
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		default:
+		}
+
+		st := time.Now()
+		r, err = host.GetDeadline(ctx, key)
+		if err != nil {
+			c.scheduler.Feedback(host, key, -10, true)
+			if i < c.N {
+				errored = append(errored, host)
+			}
+		} else {
+			cnt++
+			if r != nil {
+				t := float64(time.Now().Sub(st)) / 1e9
+				c.scheduler.Feedback(host, key, -float64(math.Sqrt(t)*t), false)
+				for j := 0; j < i; j++ {
+					c.scheduler.Feedback(hosts[j], key, -1, false)
+				}
+				targets = []string{host.Addr}
+				err = nil
+				c.repairStale(key, r, missed, errored)
+				return
+			}
+			missed = append(missed, host)
+		}
+		if cnt >= c.R && i+1 >= c.N {
+			err = nil
+			for _, fail_host := range hosts[:3] {
+				targets = append(targets, fail_host.Addr)
+			}
+			break
+		}
+	}
+	return
+}
+
+// hedgedFraction is how far into the client's recent p99 latency we
+// wait before firing the hedge request at the second replica. tune down
+// for latency-sensitive callers, up to save backend load.
+const hedgedFraction = 0.5
+
+// hedgedGet implements HedgedGet: if the primary replica hasn't
+// answered within half its p99, the second replica is queried in
+// parallel and whichever answers first wins; the loser's request is
+// cancelled via ctx.
+//
+// handled is false if hedging isn't applicable at all (fewer than two
+// hosts, or HedgedGet was never enabled) — the caller should run its
+// normal host loop over every host. handled is true if hedging ran to a
+// conclusion (a value, or ctx expiring); if both hedged replicas miss or
+// error, handled is false and the caller continues with the rest. queried lists exactly the hosts hedgedGet already talked to (in
+// request order) and missed lists the ones that cleanly returned nil,
+// so a caller falling back to the rest of the replica list knows which
+// ones not to repeat and which ones read-repair should still consider.
+func (c *Client) hedgedGet(ctx context.Context, hosts []*Host, key string) (r *Item, targets []string, err error, handled bool, queried, missed []*Host) {
+	if !c.hedged || len(hosts) < 2 {
+		return nil, nil, nil, false, nil, nil
+	}
+
+	type result struct {
+		host *Host
+		item *Item
+		err  error
+	}
+	resCh := make(chan result, 2)
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	query := func(host *Host) {
+		st := time.Now()
+		item, e := host.GetDeadline(hedgeCtx, key)
+		t := float64(time.Now().Sub(st)) / 1e9
+		if e != nil {
+			c.scheduler.Feedback(host, key, -10, true)
+		} else {
+			c.scheduler.Feedback(host, key, -float64(math.Sqrt(t)*t), false)
+		}
+		select {
+		case resCh <- result{host, item, e}:
+		case <-hedgeCtx.Done():
+		}
+	}
+
+	go query(hosts[0])
+	queried = append(queried, hosts[0])
+	outstanding := 1
+	timer := time.NewTimer(time.Duration(float64(c.p99()) * hedgedFraction))
+	defer timer.Stop()
+
+	select {
+	case res := <-resCh:
+		outstanding--
+		if res.err == nil && res.item != nil {
+			return res.item, []string{res.host.Addr}, nil, true, queried, missed
+		}
+		if res.err == nil {
+			missed = append(missed, res.host)
+		}
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err(), true, queried, missed
+	}
+
+	go query(hosts[1])
+	queried = append(queried, hosts[1])
+	outstanding++
+	for ; outstanding > 0; outstanding-- {
+		select {
+		case res := <-resCh:
+			if res.err == nil && res.item != nil {
+				return res.item, []string{res.host.Addr}, nil, true, queried, missed
+			}
+			if res.err == nil {
+				missed = append(missed, res.host)
+			}
+		case <-ctx.Done():
+			return nil, nil, ctx.Err(), true, queried, missed
+		}
+	}
+	// both replicas missed or errored: fall through to the normal path
+	// for the remaining hosts rather than reporting a hard failure.
+	return nil, nil, nil, false, queried, missed
+}
+
+// p99 is the latency HedgedGet waits before firing the hedge. a real
+// deployment would feed this from the scheduler's observed latencies;
+// until SetP99Estimate is called we fall back to a conservative default.
+func (c *Client) p99() time.Duration {
+	if c.p99Estimate > 0 {
+		return c.p99Estimate
+	}
+	return 50 * time.Millisecond
+}
+
+// EnableHedgedGet turns HedgedGet on or off for GetContext.
+func (c *Client) EnableHedgedGet(on bool) {
+	c.hedged = on
+}
+
+// SetP99Estimate feeds HedgedGet a latency estimate to hedge against,
+// typically refreshed periodically from whatever is tracking per-host
+// latencies.
+func (c *Client) SetP99Estimate(p99 time.Duration) {
+	c.p99Estimate = p99
+}
+
+// SetContext writes item to key's replicas, aborting the host loop when
+// ctx is done and pushing the remaining deadline into each host.Set.
+func (c *Client) SetContext(ctx context.Context, key string, item *Item, noreply bool) (ok bool, targets []string, final_err error) {
+	suc := 0
+	for i, host := range c.scheduler.GetHostsByKey(key) {
+		select {
+		case <-ctx.Done():
+			return false, targets, ctx.Err()
+		default:
+		}
+		if ok, err := host.SetDeadline(ctx, key, item, noreply); err == nil && ok {
+			suc++
+			targets = append(targets, host.Addr)
+		} else {
+			c.scheduler.Feedback(host, key, -2, false)
+		}
+		if suc >= c.W && (i+1) >= c.N {
+			break
+		}
+	}
+	if suc < c.W {
+		return false, targets, context.DeadlineExceeded
+	}
+	return true, targets, nil
+}
+
+// GetMultiContext fans GetMulti's per-bucket goroutines out under ctx:
+// once the context is cancelled (including by the caller after quorum
+// is already met), outstanding bucket fetches are abandoned rather than
+// awaited.
+func (c *Client) GetMultiContext(ctx context.Context, keys []string) (rs map[string]*Item, targets []string, err error) {
+	rs = make(map[string]*Item, len(keys))
+	gs := c.scheduler.DivideKeysByBucket(keys)
+
+	type bucketResult struct {
+		rs      map[string]*Item
+		targets []string
+		err     error
+	}
+	reply := make(chan bucketResult, len(gs))
+	pending := 0
+	for _, ks := range gs {
+		if len(ks) == 0 {
+			continue
+		}
+		pending++
+		go func(keys []string) {
+			r, t, e := c.getMultiContext(ctx, keys)
+			reply <- bucketResult{r, t, e}
+		}(ks)
+	}
+
+	for i := 0; i < pending; i++ {
+		select {
+		case br := <-reply:
+			if br.err != nil {
+				err = br.err
+				continue
+			}
+			for k, v := range br.rs {
+				rs[k] = v
+			}
+			targets = append(targets, br.targets...)
+		case <-ctx.Done():
+			// leave the remaining goroutines to finish in the
+			// background; their results are discarded.
+			return rs, targets, ctx.Err()
+		}
+	}
+	return
+}
+
+func (c *Client) getMultiContext(ctx context.Context, keys []string) (rs map[string]*Item, targets []string, err error) {
+	need := len(keys)
+	rs = make(map[string]*Item, need)
+	hosts := c.scheduler.GetHostsByKey(keys[0])
+	suc := 0
+	for i, host := range hosts {
+		select {
+		case <-ctx.Done():
+			return rs, targets, ctx.Err()
+		default:
+		}
+
+		st := time.Now()
+		r, er := host.GetMultiDeadline(ctx, keys)
+		if er != nil {
+			err = er
+			c.scheduler.Feedback(host, keys[0], -10, true)
+		} else {
+			suc += 1
+			targets = append(targets, host.Addr)
+		}
+
+		t := float64(time.Now().Sub(st)) / 1e9
+		c.scheduler.Feedback(host, keys[0], -float64(math.Sqrt(t)*t), false)
+		for k, v := range r {
+			rs[k] = v
+		}
+
+		if len(rs) == need {
+			break
+		}
+		if i+1 >= c.N && suc >= c.R {
+			err = nil
+			targets = []string{}
+			break
+		}
+
+		new_keys := []string{}
+		for _, k := range keys {
+			if _, ok := rs[k]; !ok {
+				new_keys = append(new_keys, k)
+			}
+		}
+		keys = new_keys
+		if len(keys) == 0 {
+			break
+		}
+	}
+	if len(rs) > 0 {
+		err = nil
+	}
+	return
+}
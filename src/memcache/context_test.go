@@ -0,0 +1,118 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowServer accepts one connection, reads the request line, then stalls
+// (never replies) until the test tears it down by closing the listener.
+func slowServer(t *testing.T) (addr string, stop func()) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		bufio.NewReader(conn).ReadString('\n')
+		<-make(chan struct{}) // block until the connection is closed out from under us
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+// fastServer answers every "get <key>" request with an immediate hit for
+// that key, so a hedgedGet race against it always wins.
+func fastServer(t *testing.T) (addr string, stop func()) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					key := "key"
+					if len(fields) == 2 {
+						key = fields[1]
+					}
+					body := "abc"
+					reply := "VALUE " + key + " 0 " + "3" + "\r\n" + body + "\r\nEND\r\n"
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+// TestHedgedGetLoserDoesNotBlockSubsequentCall guards against the hedge
+// loser pinning a Host's single connection+lock for its full timeout: the
+// slow replica should be free to serve a fresh, fast call soon after the
+// fast replica wins the hedge, not after the original (long) deadline.
+func TestHedgedGetLoserDoesNotBlockSubsequentCall(t *testing.T) {
+	slowAddr, stopSlow := slowServer(t)
+	defer stopSlow()
+	fastAddr, stopFast := fastServer(t)
+	defer stopFast()
+
+	const hostTimeout = 2 * time.Second
+	slow := NewHost(slowAddr, hostTimeout)
+	fast := NewHost(fastAddr, hostTimeout)
+
+	s := NewRendezvousScheduler()
+	s.AddHost(slow)
+	s.AddHost(fast)
+
+	c := NewClient(s, 2, 1, 1)
+	c.EnableHedgedGet(true)
+	c.SetP99Estimate(20 * time.Millisecond)
+
+	hosts := []*Host{slow, fast}
+	ctx, cancel := context.WithTimeout(context.Background(), hostTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, _, _, handled, _, _ := c.hedgedGet(ctx, hosts, "some-key")
+	if !handled {
+		t.Fatalf("expected hedgedGet to handle the request")
+	}
+	if elapsed := time.Since(start); elapsed >= hostTimeout {
+		t.Fatalf("hedgedGet took %v, expected the fast replica to win well under hostTimeout (%v)", elapsed, hostTimeout)
+	}
+
+	// the slow replica's query is still in flight, racing our own call
+	// below for h.lock; give do()'s ctx.Done watcher a moment to force the
+	// stuck read to unblock before we measure.
+	time.Sleep(20 * time.Millisecond)
+
+	callCtx, callCancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer callCancel()
+	callStart := time.Now()
+	slow.GetDeadline(callCtx, "another-key")
+	if elapsed := time.Since(callStart); elapsed >= hostTimeout {
+		t.Fatalf("a fresh call to the hedge loser's Host took %v, want well under hostTimeout (%v): "+
+			"the abandoned hedge query is still holding the Host's lock+connection", elapsed, hostTimeout)
+	}
+}
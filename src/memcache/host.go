@@ -0,0 +1,405 @@
+/*
+ * Host: a text-protocol connection to a single memcached backend
+ */
+
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Item is a single cached value together with the flags and expiration
+// memcached stores alongside it.
+type Item struct {
+	Key     string
+	Body    []byte
+	Flag    uint32
+	Exptime int32
+}
+
+// defaultHostTimeout is used when a Host is constructed without an
+// explicit timeout and the caller doesn't push a context deadline down
+// via the *Deadline methods.
+const defaultHostTimeout = time.Second
+
+// Host is a single memcached backend, spoken to over the classic text
+// protocol. it keeps one persistent connection per Host and reconnects
+// lazily on error; callers that want concurrency across backends run
+// one goroutine per Host, not per request against the same Host.
+type Host struct {
+	Addr    string
+	Timeout time.Duration
+
+	lock sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// NewHost builds a Host for addr. the connection is opened lazily on
+// first use.
+func NewHost(addr string, timeout time.Duration) *Host {
+	if timeout <= 0 {
+		timeout = defaultHostTimeout
+	}
+	return &Host{Addr: addr, Timeout: timeout}
+}
+
+// connect returns the host's connection, (re)dialing if needed. caller
+// must hold h.lock.
+func (h *Host) connect() (*bufio.ReadWriter, error) {
+	if h.conn != nil {
+		return h.rw, nil
+	}
+	conn, err := net.DialTimeout("tcp", h.Addr, h.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	h.conn = conn
+	h.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	return h.rw, nil
+}
+
+// drop closes and forgets the current connection, so the next call
+// reconnects from scratch. caller must hold h.lock.
+func (h *Host) drop() {
+	if h.conn != nil {
+		h.conn.Close()
+		h.conn = nil
+		h.rw = nil
+	}
+}
+
+// deadline resolves the effective deadline for a call: ctx's deadline if
+// it has one, otherwise now+h.Timeout. a nil ctx behaves like
+// context.Background().
+func (h *Host) deadline(ctx context.Context) time.Time {
+	if ctx != nil {
+		if dl, ok := ctx.Deadline(); ok {
+			return dl
+		}
+	}
+	return time.Now().Add(h.Timeout)
+}
+
+// do runs fn against the host's connection, applying deadline first and
+// dropping the connection on any I/O error so the next call starts
+// clean rather than reusing a connection left in an unknown state. if
+// ctx is cancelled while fn is blocked on the socket (e.g. a HedgedGet
+// loser whose sibling already answered), do forces the connection's
+// deadline to now so fn unblocks with a timeout instead of riding out
+// the full h.Timeout while holding h.lock against every other caller of
+// this Host.
+func (h *Host) do(ctx context.Context, fn func(rw *bufio.ReadWriter) error) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	rw, err := h.connect()
+	if err != nil {
+		return err
+	}
+	conn := h.conn
+	if dl := h.deadline(ctx); !dl.IsZero() {
+		conn.SetDeadline(dl)
+	}
+
+	if ctx != nil && ctx.Done() != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.SetDeadline(time.Now())
+			case <-done:
+			}
+		}()
+	}
+
+	if err := fn(rw); err != nil {
+		h.drop()
+		return err
+	}
+	return nil
+}
+
+func readLine(rw *bufio.ReadWriter) (string, error) {
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Get fetches a single key with no deadline beyond the Host's default
+// Timeout.
+func (h *Host) Get(key string) (*Item, error) {
+	return h.GetDeadline(context.Background(), key)
+}
+
+// GetDeadline fetches a single key, honoring ctx's deadline if it has
+// one.
+func (h *Host) GetDeadline(ctx context.Context, key string) (item *Item, err error) {
+	err = h.do(ctx, func(rw *bufio.ReadWriter) error {
+		if _, e := fmt.Fprintf(rw, "get %s\r\n", key); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		items, e := readValues(rw)
+		if e != nil {
+			return e
+		}
+		item = items[key]
+		return nil
+	})
+	return
+}
+
+// GetMulti fetches several keys in one round trip, with no deadline
+// beyond the Host's default Timeout.
+func (h *Host) GetMulti(keys []string) (map[string]*Item, error) {
+	return h.GetMultiDeadline(context.Background(), keys)
+}
+
+// GetMultiDeadline fetches several keys in one round trip, honoring
+// ctx's deadline if it has one. missing keys are simply absent from the
+// returned map.
+func (h *Host) GetMultiDeadline(ctx context.Context, keys []string) (items map[string]*Item, err error) {
+	if len(keys) == 0 {
+		return map[string]*Item{}, nil
+	}
+	err = h.do(ctx, func(rw *bufio.ReadWriter) error {
+		if _, e := fmt.Fprintf(rw, "get %s\r\n", strings.Join(keys, " ")); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		var e error
+		items, e = readValues(rw)
+		return e
+	})
+	return
+}
+
+// readValues reads "VALUE ..." blocks up to the terminating "END" line,
+// shared by both the single-key and multi-key get paths.
+func readValues(rw *bufio.ReadWriter) (map[string]*Item, error) {
+	items := make(map[string]*Item)
+	for {
+		line, err := readLine(rw)
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			return items, nil
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return nil, fmt.Errorf("memcache: unexpected get reply %q", line)
+		}
+		key := fields[1]
+		flag, _ := strconv.ParseUint(fields[2], 10, 32)
+		size, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, err
+		}
+
+		body := make([]byte, size+2) // +2 for the trailing "\r\n"
+		if _, err := readFull(rw, body); err != nil {
+			return nil, err
+		}
+		items[key] = &Item{Key: key, Body: body[:size], Flag: uint32(flag)}
+	}
+}
+
+func readFull(rw *bufio.ReadWriter, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := rw.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Set stores item under key. noreply skips waiting for memcached's
+// STORED/NOT_STORED reply, trading the ability to detect failure for
+// one fewer round trip.
+func (h *Host) Set(key string, item *Item, noreply bool) (bool, error) {
+	return h.SetDeadline(context.Background(), key, item, noreply)
+}
+
+// SetDeadline stores item under key, honoring ctx's deadline if it has
+// one.
+func (h *Host) SetDeadline(ctx context.Context, key string, item *Item, noreply bool) (ok bool, err error) {
+	err = h.do(ctx, func(rw *bufio.ReadWriter) error {
+		suffix := ""
+		if noreply {
+			suffix = " noreply"
+		}
+		if _, e := fmt.Fprintf(rw, "set %s %d %d %d%s\r\n", key, item.Flag, item.Exptime, len(item.Body), suffix); e != nil {
+			return e
+		}
+		if _, e := rw.Write(item.Body); e != nil {
+			return e
+		}
+		if _, e := rw.WriteString("\r\n"); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		if noreply {
+			ok = true
+			return nil
+		}
+		line, e := readLine(rw)
+		if e != nil {
+			return e
+		}
+		ok = line == "STORED"
+		return nil
+	})
+	return
+}
+
+// Append appends value to whatever key already holds.
+func (h *Host) Append(key string, value []byte) (ok bool, err error) {
+	err = h.do(context.Background(), func(rw *bufio.ReadWriter) error {
+		if _, e := fmt.Fprintf(rw, "append %s 0 0 %d\r\n", key, len(value)); e != nil {
+			return e
+		}
+		if _, e := rw.Write(value); e != nil {
+			return e
+		}
+		if _, e := rw.WriteString("\r\n"); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		line, e := readLine(rw)
+		if e != nil {
+			return e
+		}
+		ok = line == "STORED"
+		return nil
+	})
+	return
+}
+
+// Incr increments key by value, returning the new value, or 0 if key
+// wasn't found.
+func (h *Host) Incr(key string, value int) (result int, err error) {
+	err = h.do(context.Background(), func(rw *bufio.ReadWriter) error {
+		if _, e := fmt.Fprintf(rw, "incr %s %d\r\n", key, value); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		line, e := readLine(rw)
+		if e != nil {
+			return e
+		}
+		if line == "NOT_FOUND" {
+			result = 0
+			return nil
+		}
+		result, e = strconv.Atoi(line)
+		return e
+	})
+	return
+}
+
+// Delete removes key.
+func (h *Host) Delete(key string) (ok bool, err error) {
+	err = h.do(context.Background(), func(rw *bufio.ReadWriter) error {
+		if _, e := fmt.Fprintf(rw, "delete %s\r\n", key); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		line, e := readLine(rw)
+		if e != nil {
+			return e
+		}
+		ok = line == "DELETED"
+		return nil
+	})
+	return
+}
+
+// StatsItemSlabs runs "stats items" and returns one slabSummary per
+// slab class memcached reports items for.
+func (h *Host) StatsItemSlabs() (slabs []slabSummary, err error) {
+	err = h.do(context.Background(), func(rw *bufio.ReadWriter) error {
+		if _, e := rw.WriteString("stats items\r\n"); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		for {
+			line, e := readLine(rw)
+			if e != nil {
+				return e
+			}
+			if line == "END" {
+				return nil
+			}
+			if s, ok := parseStatsItemsLine(line); ok {
+				slabs = append(slabs, s)
+			}
+		}
+	})
+	return
+}
+
+// StatsCachedump runs "stats cachedump <slab> <limit>" and returns the
+// keys memcached reports for that slab. this is inherently a sample:
+// memcached itself bounds how much a single cachedump call returns, so
+// a slab with more live items than that bound will be under-reported.
+func (h *Host) StatsCachedump(slab slabSummary, limit int) (keys []dumpedKey, err error) {
+	err = h.do(context.Background(), func(rw *bufio.ReadWriter) error {
+		if _, e := fmt.Fprintf(rw, "stats cachedump %d %d\r\n", slab.id, limit); e != nil {
+			return e
+		}
+		if e := rw.Flush(); e != nil {
+			return e
+		}
+		for {
+			line, e := readLine(rw)
+			if e != nil {
+				return e
+			}
+			if line == "END" {
+				return nil
+			}
+			if dk, ok := parseCachedumpLine(line); ok {
+				keys = append(keys, dk)
+			}
+		}
+	})
+	return
+}
+
+// Close releases the host's connection. the Host can still be used
+// afterward; the next call simply reconnects.
+func (h *Host) Close() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.drop()
+	return nil
+}
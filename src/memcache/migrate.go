@@ -0,0 +1,116 @@
+/*
+ * live bucket migration: drain a Migrating bucket from its old owner to
+ * its new owner without taking the cluster offline.
+ */
+
+package memcache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMigrating is surfaced by Get when a key's bucket is mid-migration
+// and neither the old nor the new owner had the item; the caller can
+// treat this the same as a miss, or retry once the migration finishes.
+var ErrMigrating = errors.New("memcache: bucket migrating")
+
+// ErrMoved tells a caller that this Client's BucketMap is stale: the
+// bucket it just queried has already finished migrating away from the
+// owner this Client tried. a coordinating proxy should fetch a fresh
+// BucketMap and retry there rather than keep serving from the old
+// owner.
+var ErrMoved = errors.New("memcache: bucket moved, refresh BucketMap")
+
+// Migrator drains Migrating buckets in the background: for each one it
+// copies every key that the same stats-items/cachedump walk Scan uses
+// reports on the old owner's host to the new owner, then flips the
+// bucket to Stable once that walk completes. Keys are not filtered by
+// bucket, and keys cachedump does not report are not copied.
+type Migrator struct {
+	client    *Client
+	scheduler BucketAware
+}
+
+// NewMigrator builds a Migrator for client. it returns an error if
+// client's scheduler doesn't support BucketMaps.
+func NewMigrator(client *Client) (*Migrator, error) {
+	ba, ok := client.scheduler.(BucketAware)
+	if !ok {
+		return nil, errors.New("memcache: scheduler does not implement BucketAware")
+	}
+	return &Migrator{client: client, scheduler: ba}, nil
+}
+
+// Run drains every bucket currently marked Migrating. it copies buckets
+// one at a time; callers that want parallelism can run several
+// Migrators, one per bucket, themselves. Buckets that finish draining
+// are flipped to Stable and the map is republished under a new version;
+// ctx cancellation stops the walk (leaving undrained buckets Migrating
+// for a future Run to pick back up).
+func (mg *Migrator) Run(ctx context.Context) error {
+	m := mg.scheduler.CurrentBucketMap()
+	if m == nil {
+		return nil
+	}
+	hosts := mg.client.scheduler.GetHostsByKey("")
+
+	next := make([]Bucket, len(m.Buckets))
+	copy(next, m.Buckets)
+	changed := false
+	var runErr error
+
+	for i, b := range next {
+		if b.State != BucketMigrating {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			runErr = err
+			break
+		}
+		owner, newOwner, _ := hostsForBucket(hosts, b)
+		if owner == nil || newOwner == nil {
+			// one side of the migration isn't known to this scheduler
+			// instance (e.g. the host hasn't been added here yet);
+			// leave the bucket Migrating for a later Run.
+			continue
+		}
+		if err := mg.drainBucket(ctx, owner, newOwner); err != nil {
+			runErr = err
+			break
+		}
+		next[i] = Bucket{Owner: b.NewOwner, Replicas: b.Replicas, State: BucketStable}
+		changed = true
+	}
+
+	if changed {
+		mg.scheduler.SetBucketMap(m.Version+1, &BucketMap{Version: m.Version + 1, Buckets: next})
+	}
+	return runErr
+}
+
+func (mg *Migrator) drainBucket(ctx context.Context, owner, newOwner *Host) error {
+	slabs, err := owner.StatsItemSlabs()
+	if err != nil {
+		return err
+	}
+	for _, slab := range slabs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		keys, err := owner.StatsCachedump(slab, cachedumpLimit)
+		if err != nil {
+			return err
+		}
+		for _, dk := range keys {
+			item, err := owner.Get(dk.key)
+			if err != nil || item == nil {
+				continue // already expired or evicted; nothing to copy
+			}
+			if _, err := newOwner.Set(dk.key, item, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+/*
+ * rendezvous (HRW) scheduler
+ */
+
+package memcache
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// decay half-life for the penalty a host accumulates from Feedback, in
+// seconds. a host that errors out repeatedly drops down the ranking for
+// a while, then recovers once it has been quiet.
+const penaltyHalfLife = 30.0
+
+// penalty tracks an exponentially-decayed score for a single
+// (host, key-bucket) pair, so that Feedback can bias the HRW ranking
+// away from hosts that are currently misbehaving.
+type penalty struct {
+	value    float64
+	lastSeen time.Time
+}
+
+// RendezvousScheduler selects hosts for a key with Highest Random Weight
+// (rendezvous) hashing: every candidate host gets a weight derived from
+// hash64(nodeID, key), and the hosts are returned sorted by descending
+// weight. unlike a fixed bucket table, adding or removing a single host
+// only reshuffles ~1/N of the keys, which keeps the warm-cache hit rate
+// stable across membership changes.
+type RendezvousScheduler struct {
+	lock  sync.RWMutex
+	hosts []*Host
+	seeds map[*Host]uint64
+
+	penaltyLock sync.Mutex
+	penalties   map[string]*penalty // key: host.Addr + "/" + keyBucket(key)
+
+	bucketMapHolder // gives us SetBucketMap/CurrentBucketMap for free
+	breakers        // gives us IsHealthy (HealthAware) for free
+}
+
+// NewRendezvousScheduler builds a scheduler with no hosts; call AddHost
+// to populate it.
+func NewRendezvousScheduler() *RendezvousScheduler {
+	s := new(RendezvousScheduler)
+	s.seeds = make(map[*Host]uint64)
+	s.penalties = make(map[string]*penalty)
+	return s
+}
+
+// AddHost registers a host as an HRW candidate. it is safe to call
+// concurrently with GetHostsByKey.
+func (s *RendezvousScheduler) AddHost(host *Host) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, h := range s.hosts {
+		if h == host {
+			return
+		}
+	}
+	s.hosts = append(s.hosts, host)
+	s.seeds[host] = hash64([]byte(host.Addr), 0)
+}
+
+// RemoveHost drops a host from the candidate set. existing rankings for
+// the remaining hosts are unaffected, so only the keys that used to
+// pick `host` first will move.
+func (s *RendezvousScheduler) RemoveHost(host *Host) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, h := range s.hosts {
+		if h == host {
+			s.hosts = append(s.hosts[:i], s.hosts[i+1:]...)
+			delete(s.seeds, host)
+			return
+		}
+	}
+}
+
+type weightedHost struct {
+	host   *Host
+	weight float64
+}
+
+// GetHostsByKey returns hosts for key, with whatever BucketMap is
+// installed taking priority over plain HRW ranking: if key's bucket has
+// a declared Owner, that host is returned first (while the bucket is
+// Migrating, NewOwner comes first and Owner right after it), followed by the rest of the
+// hosts in HRW order as fallbacks. with no BucketMap installed this is
+// pure HRW: hosts[0] is the primary owner and hosts[1:] are fallbacks in
+// ranking order.
+//
+// routing this way — in the scheduler itself, rather than leaving it to
+// each caller — is what makes the bucket map authoritative for every
+// operation (Get, Set, Append, Incr, Delete, ...) during a migration,
+// not just the ones a caller remembered to special-case.
+func (s *RendezvousScheduler) GetHostsByKey(key string) []*Host {
+	ranked := s.hrwRank(key)
+
+	m := s.CurrentBucketMap()
+	if m == nil {
+		return ranked
+	}
+	i := m.BucketFor(key)
+	if i < 0 {
+		return ranked
+	}
+	b := m.Buckets[i]
+	owner, newOwner, _ := hostsForBucket(ranked, b)
+	if owner == nil && newOwner == nil {
+		return ranked
+	}
+
+	authoritative := make([]*Host, 0, 2)
+	if newOwner != nil {
+		authoritative = append(authoritative, newOwner)
+	}
+	if owner != nil {
+		authoritative = append(authoritative, owner)
+	}
+	ordered := make([]*Host, 0, len(ranked))
+	ordered = append(ordered, authoritative...)
+	for _, h := range ranked {
+		if h == owner || h == newOwner {
+			continue
+		}
+		ordered = append(ordered, h)
+	}
+	return ordered
+}
+
+// hrwRank returns every known host ordered by descending HRW weight for
+// key, ignoring any installed BucketMap.
+func (s *RendezvousScheduler) hrwRank(key string) []*Host {
+	s.lock.RLock()
+	hosts := make([]*Host, len(s.hosts))
+	copy(hosts, s.hosts)
+	seeds := make([]uint64, len(hosts))
+	for i, h := range hosts {
+		seeds[i] = s.seeds[h]
+	}
+	s.lock.RUnlock()
+
+	kb := []byte(key)
+	ws := make([]weightedHost, len(hosts))
+	for i, h := range hosts {
+		// normalize the hash into [0,1) before combining it with the
+		// penalty: a raw uint64 hash has ~15-17 significant digits once
+		// it's a float64, so subtracting a realistic Feedback-derived
+		// penalty (single digits, decayed) would be lost to rounding
+		// and never move the ranking at all.
+		w := float64(hash64(kb, seeds[i])) / (1 << 64)
+		ws[i] = weightedHost{h, w - s.getPenalty(h, key)}
+	}
+	sort.Slice(ws, func(i, j int) bool { return ws[i].weight > ws[j].weight })
+
+	ranked := make([]*Host, len(ws))
+	for i, w := range ws {
+		ranked[i] = w.host
+	}
+	return ranked
+}
+
+// DivideKeysByBucket groups keys by their top-ranked host, so a caller
+// fanning out GetMulti can issue one request per host instead of one
+// per key. like GetHostsByKey, whatever BucketMap is installed takes
+// priority over plain HRW ranking, so a live migration is honored by
+// multi-key ops too: a key whose bucket has a declared Owner groups
+// under that host (under NewOwner instead, while Migrating) rather than
+// under the stale HRW pick.
+func (s *RendezvousScheduler) DivideKeysByBucket(keys []string) [][]string {
+	s.lock.RLock()
+	hosts := make([]*Host, len(s.hosts))
+	copy(hosts, s.hosts)
+	seeds := make([]uint64, len(hosts))
+	for i, h := range hosts {
+		seeds[i] = s.seeds[h]
+	}
+	s.lock.RUnlock()
+
+	m := s.CurrentBucketMap()
+
+	byHost := make(map[*Host][]string, len(hosts))
+	for _, key := range keys {
+		var best *Host
+		var bestWeight float64
+		kb := []byte(key)
+		for i, h := range hosts {
+			// penalty intentionally ignored for grouping: stays stable
+			// under transient errors
+			fw := float64(hash64(kb, seeds[i]))
+			if best == nil || fw > bestWeight {
+				best, bestWeight = h, fw
+			}
+		}
+		if best == nil {
+			continue
+		}
+		if m != nil {
+			if i := m.BucketFor(key); i >= 0 {
+				owner, newOwner, _ := hostsForBucket(hosts, m.Buckets[i])
+				if newOwner != nil {
+					best = newOwner
+				} else if owner != nil {
+					best = owner
+				}
+			}
+		}
+		byHost[best] = append(byHost[best], key)
+	}
+
+	groups := make([][]string, 0, len(byHost))
+	for _, ks := range byHost {
+		groups = append(groups, ks)
+	}
+	return groups
+}
+
+// Feedback biases future rankings by decaying a penalty onto (host,
+// key-bucket): persistent failures push a host down the HRW order
+// without needing to remove it outright. it also feeds the host's
+// circuit breaker (see breakers/IsHealthy), independent of the HRW
+// penalty: a host can keep ranking acceptably for fresh keys while still
+// tripping its breaker on repeated hard failures.
+func (s *RendezvousScheduler) Feedback(host *Host, key string, score float64, fail bool) {
+	if fail {
+		s.breakers.recordFailure(host)
+	} else {
+		s.breakers.recordSuccess(host)
+	}
+	if !fail && score >= 0 {
+		return
+	}
+	bucket := keyBucket(key)
+	id := host.Addr + "/" + bucket
+
+	s.penaltyLock.Lock()
+	defer s.penaltyLock.Unlock()
+	p, ok := s.penalties[id]
+	if !ok {
+		p = &penalty{lastSeen: time.Now()}
+		s.penalties[id] = p
+	}
+	p.value = decay(p.value, p.lastSeen) - score
+	p.lastSeen = time.Now()
+}
+
+func (s *RendezvousScheduler) getPenalty(host *Host, key string) float64 {
+	id := host.Addr + "/" + keyBucket(key)
+	s.penaltyLock.Lock()
+	defer s.penaltyLock.Unlock()
+	p, ok := s.penalties[id]
+	if !ok {
+		return 0
+	}
+	return decay(p.value, p.lastSeen)
+}
+
+// keyBucket coarsens a key down to a small bucket identity so that
+// penalties apply to "this kind of key on this host" rather than to
+// every individual key, which would make the penalty map grow without
+// bound.
+func keyBucket(key string) string {
+	const buckets = 64
+	h := hash64([]byte(key), 0)
+	return string(rune('a' + h%buckets))
+}
+
+func decay(value float64, since time.Time) float64 {
+	if value == 0 {
+		return 0
+	}
+	elapsed := time.Since(since).Seconds()
+	return value * math.Pow(2, -elapsed/penaltyHalfLife)
+}
+
+// hash64 mixes seed into an FNV-1a 64-bit hash of data, so different
+// clusters (different seeds) don't end up with correlated rankings.
+func hash64(data []byte, seed uint64) uint64 {
+	h := fnv.New64a()
+	var seedBuf [8]byte
+	for i := 0; i < 8; i++ {
+		seedBuf[i] = byte(seed >> (8 * uint(i)))
+	}
+	h.Write(seedBuf[:])
+	h.Write(data)
+	return h.Sum64()
+}
@@ -0,0 +1,118 @@
+package memcache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRendezvousSchedulerStableUnderAddRemove(t *testing.T) {
+	s := NewRendezvousScheduler()
+	hosts := make([]*Host, 8)
+	for i := range hosts {
+		hosts[i] = NewHost("127.0.0.1:0", 0)
+		s.AddHost(hosts[i])
+	}
+
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = "key-" + string(rune('a'+i%26)) + string(rune('0'+i%10))
+	}
+
+	// GetHostsByKey and DivideKeysByBucket read s.seeds; AddHost/RemoveHost
+	// write it under the same lock. Run them concurrently so `go test
+	// -race` catches a regression of the "read after unlock" bug.
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, k := range keys {
+				if got := s.GetHostsByKey(k); len(got) == 0 {
+					t.Errorf("GetHostsByKey(%q) returned no hosts", k)
+				}
+			}
+			s.DivideKeysByBucket(keys)
+		}()
+	}
+
+	extra := NewHost("127.0.0.1:1", 0)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.AddHost(extra)
+		s.RemoveHost(extra)
+	}()
+
+	wg.Wait()
+}
+
+func TestRendezvousSchedulerAddRemoveReshufflesOnlyOneNth(t *testing.T) {
+	s := NewRendezvousScheduler()
+	hosts := make([]*Host, 10)
+	for i := range hosts {
+		hosts[i] = NewHost("127.0.0.1:0", 0)
+		s.AddHost(hosts[i])
+	}
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = "key-" + string(rune(i))
+	}
+	before := make(map[string]*Host, len(keys))
+	for _, k := range keys {
+		before[k] = s.GetHostsByKey(k)[0]
+	}
+
+	newHost := NewHost("127.0.0.1:2", 0)
+	s.AddHost(newHost)
+
+	moved := 0
+	for _, k := range keys {
+		if s.GetHostsByKey(k)[0] != before[k] {
+			moved++
+		}
+	}
+
+	// expect roughly 1/(N+1) of keys to move to the new host; allow a
+	// generous margin since this is a statistical property, not exact.
+	n := len(hosts) + 1
+	if moved > len(keys)/n*3 {
+		t.Errorf("adding one host moved %d/%d keys, want roughly %d", moved, len(keys), len(keys)/n)
+	}
+}
+
+func TestFeedbackPenaltyMovesRanking(t *testing.T) {
+	s := NewRendezvousScheduler()
+	hosts := make([]*Host, 6)
+	for i := range hosts {
+		// distinct addrs: penalties are keyed by Addr, so hosts sharing one
+		// would all be penalized together.
+		hosts[i] = NewHost("10.0.0."+string(rune('1'+i))+":11211", 0)
+		s.AddHost(hosts[i])
+	}
+
+	// find a key whose top-ranked host isn't already last, so hammering
+	// that host with failures has somewhere to move to.
+	var key string
+	var top *Host
+	for i := 0; i < 100; i++ {
+		k := "penalty-key-" + string(rune('a'+i))
+		ranked := s.GetHostsByKey(k)
+		if ranked[0] != ranked[len(ranked)-1] {
+			key, top = k, ranked[0]
+			break
+		}
+	}
+	if top == nil {
+		t.Fatalf("could not find a usable key among candidates")
+	}
+
+	for i := 0; i < 5; i++ {
+		s.Feedback(top, key, -10, true)
+	}
+
+	if got := s.GetHostsByKey(key)[0]; got == top {
+		t.Errorf("repeated hard-failure Feedback for %s against %s did not change its rank: "+
+			"the penalty is being lost to float64 rounding against the normalized hash weight", key, top.Addr)
+	}
+}
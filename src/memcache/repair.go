@@ -0,0 +1,147 @@
+/*
+ * read-repair: heal stale replicas discovered during Get
+ */
+
+package memcache
+
+import "sync"
+
+// RepairStats are the running counters for the read-repair subsystem,
+// safe to read concurrently with the repairs it describes.
+type RepairStats struct {
+	lock       sync.Mutex
+	Attempted  int64
+	Succeeded  int64
+	Coalesced  int64
+}
+
+func (s *RepairStats) attempted() {
+	s.lock.Lock()
+	s.Attempted++
+	s.lock.Unlock()
+}
+
+func (s *RepairStats) succeeded() {
+	s.lock.Lock()
+	s.Succeeded++
+	s.lock.Unlock()
+}
+
+func (s *RepairStats) coalesced() {
+	s.lock.Lock()
+	s.Coalesced++
+	s.lock.Unlock()
+}
+
+// Snapshot returns a copy of the counters, safe to read without
+// racing the repair workers.
+func (s *RepairStats) Snapshot() RepairStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return RepairStats{Attempted: s.Attempted, Succeeded: s.Succeeded, Coalesced: s.Coalesced}
+}
+
+const repairWorkers = 8
+
+// repairer drives read-repair: Get hands it (stale hosts, key, item) and
+// it writes the fresh value back to the stale hosts on a bounded pool of
+// background goroutines, coalescing repairs already in flight for the
+// same key.
+type repairer struct {
+	enabled   bool
+	stats     RepairStats
+	scheduler Scheduler // consulted for HealthAware, if it implements it
+
+	jobs chan repairJob
+
+	lock    sync.Mutex
+	inFlight map[string]bool
+}
+
+type repairJob struct {
+	hosts []*Host
+	key   string
+	item  *Item
+}
+
+func newRepairer(scheduler Scheduler) *repairer {
+	r := &repairer{
+		scheduler: scheduler,
+		jobs:      make(chan repairJob, 1024),
+		inFlight:  make(map[string]bool),
+	}
+	for i := 0; i < repairWorkers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+func (r *repairer) work() {
+	for job := range r.jobs {
+		r.processJob(job)
+	}
+}
+
+func (r *repairer) processJob(job repairJob) {
+	for _, host := range job.hosts {
+		if ha, ok := r.scheduler.(HealthAware); ok && !ha.IsHealthy(host) {
+			// host's circuit is already open from ordinary traffic;
+			// don't pile repair writes onto it too.
+			continue
+		}
+		r.stats.attempted()
+		if ok, err := host.Set(job.key, job.item, true); err == nil && ok {
+			r.stats.succeeded()
+		}
+	}
+	r.lock.Lock()
+	delete(r.inFlight, job.key)
+	r.lock.Unlock()
+}
+
+// submit queues a repair for key against hosts, unless a repair for the
+// same key is already in flight. non-blocking: if the queue is full the
+// repair is dropped rather than stalling the caller's Get.
+func (r *repairer) submit(hosts []*Host, key string, item *Item) {
+	if len(hosts) == 0 {
+		return
+	}
+	r.lock.Lock()
+	if r.inFlight[key] {
+		r.lock.Unlock()
+		r.stats.coalesced()
+		return
+	}
+	r.inFlight[key] = true
+	r.lock.Unlock()
+
+	select {
+	case r.jobs <- repairJob{hosts: hosts, key: key, item: item}:
+	default:
+		r.lock.Lock()
+		delete(r.inFlight, key)
+		r.lock.Unlock()
+	}
+}
+
+// EnableReadRepair turns read-repair on or off. when on, a successful Get
+// that skipped over earlier nil or errored replicas asynchronously
+// writes the found value back to them, so they stop serving stale misses
+// on the next read.
+func (c *Client) EnableReadRepair(on bool) {
+	if on && c.repair == nil {
+		c.repair = newRepairer(c.scheduler)
+	}
+	if c.repair != nil {
+		c.repair.enabled = on
+	}
+}
+
+// RepairStats returns the current read-repair counters. it returns a
+// zero value if read-repair has never been enabled.
+func (c *Client) RepairStats() RepairStats {
+	if c.repair == nil {
+		return RepairStats{}
+	}
+	return c.repair.stats.Snapshot()
+}
@@ -0,0 +1,207 @@
+/*
+ * key iteration via memcached's "stats items" / "stats cachedump"
+ */
+
+package memcache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// cachedumpLimit bounds how many keys we ask memcached for per slab in
+// one "stats cachedump" call. memcached itself caps this internally
+// (historically at 1MB of dump output), so cachedump is inherently a
+// sample of a slab, not a guaranteed-complete listing; Scan re-issues it
+// per slab but can still miss keys that churn out of the LRU between
+// calls.
+const cachedumpLimit = 5000
+
+// scanBatch is how many keys Scan fetches with a single "get" once it
+// has a batch of names from cachedump.
+const scanBatch = 200
+
+// dumpedKey is one entry read back from "stats cachedump".
+type dumpedKey struct {
+	key        string
+	size       int
+	expiration int64
+}
+
+// Scan enumerates keys across every host the scheduler knows about,
+// calling fn with each key's current value. it walks each host's slab
+// classes via "stats items" and "stats cachedump <slab> <limit>", then
+// fetches the resulting keys in batches. Hosts are walked one after
+// another and results are streamed, so de-duplication across replicas
+// is partial: a key already delivered is skipped unless a later host
+// reports a later expiration for it, in which case fn is called for
+// that key again with the later host's value. fn may return false to
+// stop the scan early. prefix, if non-empty, is applied client-side
+// since memcached's cachedump has no prefix filter of its own.
+//
+// memcached's cachedump only returns a bounded sample of each slab
+// (historically capped around 1MB of dump text), so Scan is best-effort
+// enumeration, not a guaranteed-complete listing of the keyspace.
+func (c *Client) Scan(ctx context.Context, prefix string, fn func(key string, item *Item) bool) error {
+	seen := make(map[string]int64) // key -> expiration already delivered
+
+	// GetHostsByKey returns every host ranked for the given key; the key
+	// itself doesn't matter here since Scan needs the whole host set,
+	// not a particular key's replicas.
+	for _, host := range c.scheduler.GetHostsByKey("") {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.scanHost(ctx, host, prefix, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) scanHost(ctx context.Context, host *Host, prefix string, seen map[string]int64, fn func(key string, item *Item) bool) error {
+	slabs, err := host.StatsItemSlabs()
+	if err != nil {
+		return err
+	}
+
+	for _, slab := range slabs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		keys, err := host.StatsCachedump(slab, cachedumpLimit)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]string, 0, scanBatch)
+		flush := func() (bool, error) {
+			if len(batch) == 0 {
+				return true, nil
+			}
+			items, err := host.GetMultiDeadline(ctx, batch)
+			batch = batch[:0]
+			if err != nil {
+				return true, err
+			}
+			for k, item := range items {
+				if !fn(k, item) {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+
+		for _, dk := range keys {
+			if prefix != "" && !strings.HasPrefix(dk.key, prefix) {
+				continue
+			}
+			if prevExp, ok := seen[dk.key]; ok && prevExp >= dk.expiration {
+				continue // a fresher replica already delivered this key
+			}
+			seen[dk.key] = dk.expiration
+			batch = append(batch, dk.key)
+			if len(batch) >= scanBatch {
+				cont, err := flush()
+				if err != nil || !cont {
+					return err
+				}
+			}
+		}
+		if cont, err := flush(); err != nil || !cont {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns an approximate count of keys held across the cluster, by
+// summing each host's "stats items" item counts per slab. it is an
+// estimate: replicated keys are counted once per replica that holds
+// them, since "stats items" doesn't expose enough to de-duplicate
+// without a full Scan.
+func (c *Client) Len() int {
+	total := 0
+	for _, host := range c.scheduler.GetHostsByKey("") {
+		slabs, err := host.StatsItemSlabs()
+		if err != nil {
+			continue
+		}
+		for _, slab := range slabs {
+			total += slab.count
+		}
+	}
+	return total
+}
+
+// parseCachedumpLine parses one line of "stats cachedump <slab> <limit>"
+// output, of the form:
+//
+//	ITEM <key> [<size> b; <expiration> s]
+func parseCachedumpLine(line string) (dumpedKey, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "ITEM ") {
+		return dumpedKey{}, false
+	}
+	rest := line[len("ITEM "):]
+	sp := strings.IndexByte(rest, ' ')
+	if sp < 0 {
+		return dumpedKey{}, false
+	}
+	key := rest[:sp]
+
+	bracket := strings.IndexByte(rest, '[')
+	if bracket < 0 {
+		return dumpedKey{key: key}, true
+	}
+	fields := strings.FieldsFunc(rest[bracket+1:], func(r rune) bool {
+		return r == ';' || r == ']'
+	})
+	var size int
+	var exp int64
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		switch {
+		case strings.HasSuffix(f, " b"):
+			size, _ = strconv.Atoi(strings.TrimSuffix(f, " b"))
+		case strings.HasSuffix(f, " s"):
+			exp, _ = strconv.ParseInt(strings.TrimSuffix(f, " s"), 10, 64)
+		}
+	}
+	return dumpedKey{key: key, size: size, expiration: exp}, true
+}
+
+// slabSummary is one row of "stats items", identifying a slab class and
+// how many live items memcached reports for it.
+type slabSummary struct {
+	id    int
+	count int
+}
+
+// parseStatsItemsLine parses a "STAT items:<id>:number <count>" line.
+func parseStatsItemsLine(line string) (slabSummary, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "STAT items:") {
+		return slabSummary{}, false
+	}
+	rest := strings.TrimPrefix(line, "STAT items:")
+	parts := strings.SplitN(rest, ":", 2)
+	if len(parts) != 2 {
+		return slabSummary{}, false
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return slabSummary{}, false
+	}
+	kv := strings.Fields(parts[1])
+	if len(kv) != 2 || kv[0] != "number" {
+		return slabSummary{}, false
+	}
+	count, err := strconv.Atoi(kv[1])
+	if err != nil {
+		return slabSummary{}, false
+	}
+	return slabSummary{id: id, count: count}, true
+}
@@ -0,0 +1,81 @@
+package memcache
+
+import "testing"
+
+func TestParseCachedumpLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want dumpedKey
+		ok   bool
+	}{
+		{
+			line: "ITEM foo [3 b; 1234 s]",
+			want: dumpedKey{key: "foo", size: 3, expiration: 1234},
+			ok:   true,
+		},
+		{
+			line: "ITEM bar-baz [0 b; -1 s]",
+			want: dumpedKey{key: "bar-baz", size: 0, expiration: -1},
+			ok:   true,
+		},
+		{
+			line: "ITEM nobracket extra",
+			want: dumpedKey{key: "nobracket"},
+			ok:   true,
+		},
+		{
+			line: "END",
+			ok:   false,
+		},
+		{
+			line: "",
+			ok:   false,
+		},
+	}
+	for _, tc := range cases {
+		got, ok := parseCachedumpLine(tc.line)
+		if ok != tc.ok {
+			t.Errorf("parseCachedumpLine(%q) ok = %v, want %v", tc.line, ok, tc.ok)
+			continue
+		}
+		if ok && got != tc.want {
+			t.Errorf("parseCachedumpLine(%q) = %+v, want %+v", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestParseStatsItemsLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want slabSummary
+		ok   bool
+	}{
+		{
+			line: "STAT items:3:number 42",
+			want: slabSummary{id: 3, count: 42},
+			ok:   true,
+		},
+		{
+			line: "STAT items:3:age 17",
+			ok:   false,
+		},
+		{
+			line: "STAT curr_connections 5",
+			ok:   false,
+		},
+		{
+			line: "STAT items:notanumber:number 1",
+			ok:   false,
+		},
+	}
+	for _, tc := range cases {
+		got, ok := parseStatsItemsLine(tc.line)
+		if ok != tc.ok {
+			t.Errorf("parseStatsItemsLine(%q) ok = %v, want %v", tc.line, ok, tc.ok)
+			continue
+		}
+		if ok && got != tc.want {
+			t.Errorf("parseStatsItemsLine(%q) = %+v, want %+v", tc.line, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package memcache
+
+// Scheduler selects and ranks hosts for a key, and is fed back the
+// outcome of using that ranking so it can adapt. RendezvousScheduler is
+// the only implementation so far, but Client and repairer only ever
+// depend on this interface, so an alternative scheduling strategy can be
+// swapped in without touching either.
+type Scheduler interface {
+	// GetHostsByKey returns the hosts that may serve key, most-preferred
+	// first.
+	GetHostsByKey(key string) []*Host
+	// DivideKeysByBucket groups keys by which host currently owns them,
+	// so a caller can issue one request per host instead of one per key.
+	DivideKeysByBucket(keys []string) [][]string
+	// Feedback reports the outcome of using host for key: score is a
+	// signed quality signal (negative is bad), and fail is set for hard
+	// failures (errors, timeouts) as opposed to ordinary cache misses.
+	Feedback(host *Host, key string, score float64, fail bool)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"memcache"
+)
+
+// rebalanceRequest is the body accepted by /rebalance: a full,
+// already-computed BucketMap plus the version it should be installed
+// under. the proxy doesn't compute rebalancing decisions itself; an
+// operator or an external coordinator posts the new map here.
+type rebalanceRequest struct {
+	Version uint64           `json:"version"`
+	Buckets []memcache.Bucket `json:"buckets"`
+}
+
+// RebalanceHandler serves the /rebalance admin endpoint: POSTing a new
+// BucketMap swaps it in atomically via scheduler.SetBucketMap, so
+// in-flight requests either see the whole old map or the whole new one,
+// never a mix.
+type RebalanceHandler struct {
+	scheduler memcache.BucketAware
+}
+
+// NewRebalanceHandler builds the /rebalance handler for scheduler. it
+// panics if scheduler is nil, since wiring it in without a scheduler is
+// a startup bug, not a runtime condition to recover from.
+func NewRebalanceHandler(scheduler memcache.BucketAware) *RebalanceHandler {
+	if scheduler == nil {
+		panic("proxy: NewRebalanceHandler requires a non-nil scheduler")
+	}
+	return &RebalanceHandler{scheduler: scheduler}
+}
+
+func (h *RebalanceHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body rebalanceRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, "bad bucket map: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body.Buckets) == 0 {
+		http.Error(w, "bucket map must have at least one bucket", http.StatusBadRequest)
+		return
+	}
+
+	current := h.scheduler.CurrentBucketMap()
+	if current != nil && body.Version <= current.Version {
+		http.Error(w, "stale version, current is newer", http.StatusConflict)
+		return
+	}
+
+	m := &memcache.BucketMap{Version: body.Version, Buckets: body.Buckets}
+	h.scheduler.SetBucketMap(body.Version, m)
+	log.Printf("rebalance: installed bucket map version %d (%d buckets)", body.Version, len(body.Buckets))
+	w.WriteHeader(http.StatusOK)
+}